@@ -1,14 +1,16 @@
 package firehose
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"os"
 
+	"github.com/segmentio/kafka-go"
 	"github.com/spf13/cobra"
 	"github.com/streamingfast/cli/sflags"
 	firecore "github.com/streamingfast/firehose-core"
+	"github.com/streamingfast/firehose-core/cmd/tools/firehose/sink"
 	"github.com/streamingfast/firehose-core/cmd/tools/print"
 	"github.com/streamingfast/firehose-core/types"
 	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
@@ -18,7 +20,7 @@ import (
 func NewToolsFirehoseClientCmd[B firecore.Block](chain *firecore.Chain[B], logger *zap.Logger) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "firehose-client <endpoint> <range>",
-		Short: "Connects to a Firehose endpoint over gRPC and print block stream as JSON to terminal",
+		Short: "Connects to a Firehose endpoint over gRPC and streams the block stream to a configurable sink",
 		Args:  cobra.ExactArgs(2),
 		RunE:  getFirehoseClientE(chain, logger),
 	}
@@ -28,11 +30,27 @@ func NewToolsFirehoseClientCmd[B firecore.Block](chain *firecore.Chain[B], logge
 	cmd.Flags().Bool("final-blocks-only", false, "Only ask for final blocks")
 	cmd.Flags().Bool("print-cursor-only", false, "Skip block decoding, only print the step cursor (useful for performance testing)")
 
-	return cmd
-}
+	cmd.Flags().String("sink", "stdout", "Output sink to write the block stream to, one of: stdout, ndjson, parquet, kafka, dstore")
+	cmd.Flags().String("cursor-checkpoint-file", "", "If set, checkpoint the cursor of the last durably flushed response to this local file so the stream can resume on restart")
+	cmd.Flags().Int("cursor-checkpoint-flush-interval", 100, "Force the sink to flush (and only then checkpoint) after at most this many responses, so batching sinks don't leave a checkpoint pointing past data they haven't persisted yet")
+
+	cmd.Flags().String("ndjson-dir", ".", "(ndjson sink) Directory to write rotated NDJSON files to")
+	cmd.Flags().String("ndjson-prefix", "blocks", "(ndjson sink) Filename prefix for rotated NDJSON files")
+	cmd.Flags().Int64("ndjson-max-bytes", 0, "(ndjson sink) Rotate to a new file once the current one reaches this size, 0 disables size-based rotation")
+	cmd.Flags().Duration("ndjson-max-age", 0, "(ndjson sink) Rotate to a new file once the current one has been open this long, 0 disables time-based rotation")
+	cmd.Flags().Bool("ndjson-gzip", false, "(ndjson sink) Gzip-compress rotated NDJSON files")
+
+	cmd.Flags().String("parquet-path", "blocks.parquet", "(parquet sink) Output Parquet file path")
+	cmd.Flags().Int("parquet-row-group-size", 10_000, "(parquet sink) Number of rows buffered per row group")
 
-type respChan struct {
-	ch chan string
+	cmd.Flags().StringSlice("kafka-brokers", nil, "(kafka sink) Kafka broker addresses")
+	cmd.Flags().String("kafka-topic", "", "(kafka sink) Kafka topic to produce to")
+	cmd.Flags().Int("kafka-required-acks", -1, "(kafka sink) Required acks: -1 (all), 0 (none), 1 (leader)")
+
+	cmd.Flags().String("dstore-url", "", "(dstore sink) Destination store URL (local path, s3://, gs://, az://, ...)")
+	cmd.Flags().Int("dstore-batch-size", 100, "(dstore sink) Number of responses batched per uploaded object")
+
+	return cmd
 }
 
 func getFirehoseClientE[B firecore.Block](chain *firecore.Chain[B], rootLog *zap.Logger) func(cmd *cobra.Command, args []string) error {
@@ -75,63 +93,177 @@ func getFirehoseClientE[B firecore.Block](chain *firecore.Chain[B], rootLog *zap
 		}
 		rootLog.Info("connected")
 
-		resps := make(chan *respChan, 10)
-		allDone := make(chan bool)
-
-		if !printCursorOnly {
-			// print the responses linearly
-			go func() {
-				for resp := range resps {
-					line := <-resp.ch
-					fmt.Println(line)
+		if printCursorOnly {
+			for {
+				response, err := stream.Recv()
+				if err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return fmt.Errorf("stream error while receiving: %w", err)
 				}
-				close(allDone)
-			}()
+				fmt.Printf("%s - %s\n", response.Step.String(), response.Cursor)
+			}
 		}
 
-		printer, err := print.GetOutputPrinter(cmd, chain.BlockFileDescriptor())
+		out, err := newSinkFromCmd(cmd, chain)
 		if err != nil {
-			return fmt.Errorf("unable to create output printer: %w", err)
+			return fmt.Errorf("unable to create output sink: %w", err)
 		}
+		defer out.Close()
+
+		checkpointFile := sflags.MustGetString(cmd, "cursor-checkpoint-file")
+		checkpointFlushInterval := sflags.MustGetInt(cmd, "cursor-checkpoint-flush-interval")
+
+		// Responses are handed off through this channel so the gRPC receive loop never blocks
+		// on sink I/O; a single consumer writes them to the sink in the order they arrived,
+		// which is also the order they were received in (the stream is not fanned out).
+		responses := make(chan *pbfirehose.Response, 10)
+		writeErr := make(chan error, 1)
 
+		go func() {
+			defer close(writeErr)
+
+			// Batching sinks (dstore, parquet) only durably persist on Flush, so the cursor is
+			// only checkpointed right after a successful Flush, never right after Write: a
+			// checkpoint must never point past a response the sink hasn't actually persisted
+			// yet, or a crash in between would make a resumed run skip it forever.
+			var pendingCursor string
+			var sinceFlush int
+			for response := range responses {
+				if err := out.Write(ctx, response); err != nil {
+					writeErr <- fmt.Errorf("write to sink: %w", err)
+					return
+				}
+				pendingCursor = response.Cursor
+				sinceFlush++
+
+				if checkpointFile != "" && checkpointFlushInterval > 0 && sinceFlush >= checkpointFlushInterval {
+					if err := out.Flush(ctx); err != nil {
+						writeErr <- fmt.Errorf("flush sink: %w", err)
+						return
+					}
+					if err := checkpointCursor(checkpointFile, pendingCursor); err != nil {
+						rootLog.Warn("unable to checkpoint cursor", zap.Error(err))
+					}
+					sinceFlush = 0
+				}
+			}
+
+			if err := out.Flush(ctx); err != nil {
+				writeErr <- fmt.Errorf("flush sink: %w", err)
+				return
+			}
+			if checkpointFile != "" && pendingCursor != "" {
+				if err := checkpointCursor(checkpointFile, pendingCursor); err != nil {
+					rootLog.Warn("unable to checkpoint cursor", zap.Error(err))
+				}
+			}
+			writeErr <- nil
+		}()
+
+	recvLoop:
 		for {
 			response, err := stream.Recv()
 			if err != nil {
 				if err == io.EOF {
-					break
+					break recvLoop
 				}
+				close(responses)
+				<-writeErr
 				return fmt.Errorf("stream error while receiving: %w", err)
 			}
 
-			if printCursorOnly {
-				fmt.Printf("%s - %s\n", response.Step.String(), response.Cursor)
-				continue
+			select {
+			case responses <- response:
+			case err := <-writeErr:
+				if err != nil {
+					return err
+				}
+				break recvLoop
 			}
+		}
 
-			resp := &respChan{
-				ch: make(chan string),
-			}
-			resps <- resp
+		close(responses)
+		if err := <-writeErr; err != nil {
+			return err
+		}
 
-			// async process the response
-			go func() {
-				buffer := bytes.NewBuffer(nil)
-				err := printer.PrintTo(response, buffer)
-				if err != nil {
-					rootLog.Error("marshalling to string", zap.Error(err))
-					resp.ch <- ""
-					return
-				}
+		return nil
+	}
+}
 
-				resp.ch <- buffer.String()
-			}()
+// newSinkFromCmd selects and configures the Sink implementation requested by --sink, wiring
+// in its sink-specific flags.
+func newSinkFromCmd[B firecore.Block](cmd *cobra.Command, chain *firecore.Chain[B]) (sink.Sink, error) {
+	switch sflags.MustGetString(cmd, "sink") {
+	case "", "stdout":
+		printer, err := print.GetOutputPrinter(cmd, chain.BlockFileDescriptor())
+		if err != nil {
+			return nil, fmt.Errorf("unable to create output printer: %w", err)
 		}
-		if printCursorOnly {
-			return nil
+		return sink.NewStdoutSink(printer), nil
+
+	case "ndjson":
+		printer, err := print.GetOutputPrinter(cmd, chain.BlockFileDescriptor())
+		if err != nil {
+			return nil, fmt.Errorf("unable to create output printer: %w", err)
 		}
+		return sink.NewNDJSONSink(sink.NDJSONSinkOptions{
+			Dir:      sflags.MustGetString(cmd, "ndjson-dir"),
+			Prefix:   sflags.MustGetString(cmd, "ndjson-prefix"),
+			MaxBytes: sflags.MustGetInt64(cmd, "ndjson-max-bytes"),
+			MaxAge:   sflags.MustGetDuration(cmd, "ndjson-max-age"),
+			Gzip:     sflags.MustGetBool(cmd, "ndjson-gzip"),
+		}, printer), nil
 
-		close(resps)
-		<-allDone
-		return nil
+	case "parquet":
+		blockDescriptor, err := sink.BlockMessageDescriptor(chain.BlockFileDescriptor())
+		if err != nil {
+			return nil, fmt.Errorf("unable to derive parquet schema: %w", err)
+		}
+		return sink.NewParquetSink(sink.ParquetSinkOptions{
+			Path:            sflags.MustGetString(cmd, "parquet-path"),
+			RowGroupSize:    sflags.MustGetInt(cmd, "parquet-row-group-size"),
+			BlockDescriptor: blockDescriptor,
+		})
+
+	case "kafka":
+		topic := sflags.MustGetString(cmd, "kafka-topic")
+		if topic == "" {
+			return nil, fmt.Errorf("--kafka-topic is required when --sink=kafka")
+		}
+		brokers := sflags.MustGetStringSlice(cmd, "kafka-brokers")
+		if len(brokers) == 0 {
+			return nil, fmt.Errorf("--kafka-brokers is required when --sink=kafka")
+		}
+		return sink.NewKafkaSink(sink.KafkaSinkOptions{
+			Brokers:      brokers,
+			Topic:        topic,
+			RequiredAcks: kafka.RequiredAcks(sflags.MustGetInt(cmd, "kafka-required-acks")),
+		}), nil
+
+	case "dstore":
+		storeURL := sflags.MustGetString(cmd, "dstore-url")
+		if storeURL == "" {
+			return nil, fmt.Errorf("--dstore-url is required when --sink=dstore")
+		}
+		return sink.NewDStoreSink(sink.DStoreSinkOptions{
+			StoreURL:  storeURL,
+			BatchSize: sflags.MustGetInt(cmd, "dstore-batch-size"),
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown sink %q, must be one of: stdout, ndjson, parquet, kafka, dstore", sflags.MustGetString(cmd, "sink"))
+	}
+}
+
+// checkpointCursor atomically writes the last successfully-written cursor to path, so a
+// restarted firehose-client can resume from it via --cursor.
+func checkpointCursor(path string, cursor string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cursor), 0644); err != nil {
+		return err
 	}
+	return os.Rename(tmp, path)
 }
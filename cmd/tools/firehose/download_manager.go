@@ -0,0 +1,387 @@
+package firehose
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	pbbstream "github.com/streamingfast/bstream/pb/sf/bstream/v1"
+	firecore "github.com/streamingfast/firehose-core"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+)
+
+// bundleSize is the number of blocks assembled into a single merged-blocks file before it
+// is handed to the store. It mirrors the bundle size used everywhere else in this codebase.
+const bundleSize = uint64(100)
+
+// ProgressFunc is invoked by the DownloadManager every time a shard makes forward progress,
+// so the CLI can render a per-shard progress bar.
+type ProgressFunc func(shardIndex int, lastBlockNum uint64, stopBlockNum uint64)
+
+// BlocksStream is the subset of the gRPC stream returned by the Firehose client's Blocks
+// method that the DownloadManager consumes.
+type BlocksStream interface {
+	Recv() (*pbfirehose.Response, error)
+}
+
+// BundleWriter persists a single block. The DownloadManager calls it once per block, bundle by
+// bundle in bundle order, only after the block's whole bundle has been assembled; the
+// download-from-firehose command backs it with a closure around MergedBlocksWriter.ProcessBlock.
+type BundleWriter func(blk *pbbstream.Block) error
+
+// DecodeFunc turns a raw firehose Response into a bstream Block, following whatever fallback
+// rules the chain requires (see decodeResponse in tools_download_from_firehose.go).
+type DecodeFunc func(response *pbfirehose.Response) (*pbbstream.Block, error)
+
+// shard is a contiguous, bundle-aligned sub-range of the overall download. Shards are the
+// unit of concurrency: each one is streamed independently by a worker.
+type shard struct {
+	index uint64 // 0-based, used to order shards and report progress; the dedup registry is keyed by key()
+	start uint64
+	stop  uint64 // exclusive
+}
+
+func (s shard) key() string {
+	return fmt.Sprintf("%d-%d", s.start, s.stop)
+}
+
+// bundleResult is what a worker produces every time it finishes assembling one bundleSize-wide
+// (or, for the final bundle of the whole range, shorter) run of blocks in order. Bundles are
+// emitted as soon as they're complete instead of waiting for their whole shard to finish, so
+// memory stays bounded to a few in-flight bundles and progress is durably written incrementally.
+type bundleResult struct {
+	index  uint64 // bundleIndex = first block's number / bundleSize, used to order bundles globally
+	blocks []*pbbstream.Block
+}
+
+// inflightShard is the shared future used to deduplicate overlapping shard requests: the
+// first caller to request a given shard starts the work, later callers just wait on done. Its
+// bundles are delivered to the results channel exactly once, by the execution that did the work.
+type inflightShard struct {
+	done chan struct{}
+	err  error
+}
+
+// DownloadManager splits a block range into bundle-aligned shards and downloads them
+// concurrently through a worker pool, reassembling bundles in order before writing them to
+// the destination store. It borrows the keyed-job/shared-future pattern used by transfer
+// managers like Moby's push/pull rewrite to avoid re-downloading overlapping ranges.
+type DownloadManager[B firecore.Block] struct {
+	chain       *firecore.Chain[B]
+	zlog        *zap.Logger
+	concurrency int
+	newStream   func(ctx context.Context, start, stop uint64) (BlocksStream, error)
+	decode      DecodeFunc
+	write       BundleWriter
+	onProgress  ProgressFunc
+
+	mu       sync.Mutex
+	inflight map[string]*inflightShard
+}
+
+// NewDownloadManager creates a manager ready to download [start, stop) using up to
+// concurrency parallel shard streams. newStream must open a fresh, independent Firehose
+// stream for the given sub-range (this lets each shard retry without disturbing others).
+func NewDownloadManager[B firecore.Block](
+	chain *firecore.Chain[B],
+	zlog *zap.Logger,
+	concurrency int,
+	newStream func(ctx context.Context, start, stop uint64) (BlocksStream, error),
+	decode DecodeFunc,
+	write BundleWriter,
+	onProgress ProgressFunc,
+) *DownloadManager[B] {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	return &DownloadManager[B]{
+		chain:       chain,
+		zlog:        zlog,
+		concurrency: concurrency,
+		newStream:   newStream,
+		decode:      decode,
+		write:       write,
+		onProgress:  onProgress,
+		inflight:    make(map[string]*inflightShard),
+	}
+}
+
+// Run downloads [start, stop) and writes full bundles to the destination store in order. It
+// is cancellable via ctx: once ctx is done, in-flight shards are abandoned and no partial
+// bundle is written. start mirrors pbfirehose.Request.StartBlockNum's signed type, but must
+// resolve to a non-negative absolute block number here since sharding requires a fixed range.
+func (m *DownloadManager[B]) Run(ctx context.Context, start int64, stop uint64) error {
+	if start < 0 {
+		return fmt.Errorf("download manager requires an absolute start block number, got relative value %d", start)
+	}
+
+	shards := splitIntoShards(uint64(start), stop, bundleSize, m.concurrency)
+	if len(shards) == 0 {
+		return nil
+	}
+
+	jobs := make(chan shard, len(shards))
+	for _, s := range shards {
+		jobs <- s
+	}
+	close(jobs)
+
+	// Buffered generously enough that a fast shard isn't blocked on the reorder/write side
+	// waiting for an earlier, slower shard's bundles; it's still a bounded channel, so memory
+	// use stays proportional to concurrency rather than to the whole range.
+	results := make(chan bundleResult, m.concurrency*4)
+	shardErrs := make(chan error, len(shards))
+
+	var wg sync.WaitGroup
+	workers := m.concurrency
+	if workers > len(shards) {
+		workers = len(shards)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range jobs {
+				if err := m.runShard(ctx, s, results); err != nil {
+					shardErrs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+		close(shardErrs)
+	}()
+
+	return m.reorderAndWrite(ctx, results, shardErrs, uint64(start)/bundleSize)
+}
+
+// runShard executes (or joins) the shared future for a shard, so that two callers asking
+// for overlapping ranges only pay for one underlying stream. Bundles are sent to results by
+// whichever call actually performs the work; a joining call only waits for its outcome.
+func (m *DownloadManager[B]) runShard(ctx context.Context, s shard, results chan<- bundleResult) error {
+	m.mu.Lock()
+	if existing, ok := m.inflight[s.key()]; ok {
+		m.mu.Unlock()
+		<-existing.done
+		return existing.err
+	}
+
+	job := &inflightShard{done: make(chan struct{})}
+	m.inflight[s.key()] = job
+	m.mu.Unlock()
+
+	job.err = m.downloadShardWithRetry(ctx, s, results)
+
+	m.mu.Lock()
+	delete(m.inflight, s.key())
+	m.mu.Unlock()
+
+	close(job.done)
+	return job.err
+}
+
+// downloadShardWithRetry streams a single shard, retrying with exponential backoff and
+// jitter on transient errors while preserving the cursor (start of the next not-yet-flushed
+// bundle) so a retry resumes instead of restarting the shard, or a completed bundle, from
+// scratch.
+func (m *DownloadManager[B]) downloadShardWithRetry(ctx context.Context, s shard, results chan<- bundleResult) error {
+	const maxAttempts = 8
+	const baseDelay = 500 * time.Millisecond
+	const maxDelay = 30 * time.Second
+
+	cursor := s.start
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		stream, err := m.newStream(ctx, cursor, s.stop)
+		if err != nil {
+			return fmt.Errorf("shard %s: unable to start stream: %w", s.key(), err)
+		}
+
+		streamErr := m.drainStream(ctx, stream, &cursor, s, results)
+		if streamErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		delay := backoffWithJitter(baseDelay, maxDelay, attempt)
+		m.zlog.Warn("shard stream encountered an error, retrying with backoff",
+			zap.String("shard", s.key()),
+			zap.Uint64("resume_cursor", cursor),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(streamErr),
+		)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return fmt.Errorf("shard %s: exhausted %d retry attempts", s.key(), maxAttempts)
+}
+
+// drainStream reads blocks from stream, accumulating them into the bundle currently in
+// progress (starting at *cursor) and emitting it to results as soon as it reaches bundleSize
+// blocks, or fewer for the final bundle of the whole range. *cursor only advances once a
+// bundle has been fully emitted, so a failed attempt always resumes at a bundle boundary and
+// never re-emits a bundle that was already sent to results.
+func (m *DownloadManager[B]) drainStream(ctx context.Context, stream BlocksStream, cursor *uint64, s shard, results chan<- bundleResult) error {
+	bundleStart := *cursor
+	var current []*pbbstream.Block
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		response, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		blk, err := m.decode(response)
+		if err != nil {
+			return fmt.Errorf("shard %s: decode block: %w", s.key(), err)
+		}
+
+		current = append(current, blk)
+
+		if m.onProgress != nil {
+			m.onProgress(int(s.index), blk.Number, s.stop)
+		}
+
+		next := blk.Number + 1
+		shardDone := next >= s.stop
+		if next%bundleSize == 0 || shardDone {
+			select {
+			case results <- bundleResult{index: bundleStart / bundleSize, blocks: current}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			*cursor = next
+			bundleStart = next
+			current = nil
+		}
+
+		if shardDone {
+			return nil
+		}
+	}
+}
+
+// reorderAndWrite consumes bundles as they arrive out of order and writes them to the store
+// strictly in bundle-index order, buffering bundles that finish early until the ones ahead of
+// them are flushed. firstIndex is the index of the bundle holding the range's first block. It
+// stops at the first shard error, if any.
+func (m *DownloadManager[B]) reorderAndWrite(ctx context.Context, results <-chan bundleResult, shardErrs <-chan error, firstIndex uint64) error {
+	pending := make(map[uint64][]*pbbstream.Block)
+	nextIndex := firstIndex
+
+	for results != nil || shardErrs != nil {
+		select {
+		case res, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			pending[res.index] = res.blocks
+
+			for {
+				blocks, ok := pending[nextIndex]
+				if !ok {
+					break
+				}
+				for _, blk := range blocks {
+					if err := m.write(blk); err != nil {
+						return fmt.Errorf("write bundle %d: %w", nextIndex, err)
+					}
+				}
+				delete(pending, nextIndex)
+				nextIndex++
+			}
+
+		case err, ok := <-shardErrs:
+			if !ok {
+				shardErrs = nil
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// splitIntoShards breaks [start, stop) into contiguous sub-ranges of roughly
+// (stop-start)/concurrency blocks, rounded up to a multiple of bundleSize. Every shard boundary
+// other than start and stop falls on a bundleSize boundary (only the first shard can start, and
+// only the last can end, mid-bundle) so that completed bundles never straddle two shards. When
+// start is not bundle-aligned this can yield one shard more than concurrency.
+func splitIntoShards(start, stop, bundleSize uint64, concurrency int) []shard {
+	if stop <= start {
+		return nil
+	}
+
+	total := stop - start
+	shardBlocks := total / uint64(concurrency)
+	if shardBlocks < bundleSize {
+		shardBlocks = bundleSize
+	}
+	// round up to a bundle boundary so shard boundaries stay bundle-aligned
+	if rem := shardBlocks % bundleSize; rem != 0 {
+		shardBlocks += bundleSize - rem
+	}
+
+	var shards []shard
+	cur := start
+	for cur < stop {
+		end := cur + shardBlocks
+		// snap down to a bundle boundary, so an unaligned start only affects the first shard
+		end -= end % bundleSize
+		if end > stop {
+			end = stop
+		}
+		shards = append(shards, shard{index: uint64(len(shards)), start: cur, stop: end})
+		cur = end
+	}
+
+	sort.Slice(shards, func(i, j int) bool { return shards[i].index < shards[j].index })
+	return shards
+}
+
+// backoffWithJitter computes an exponential backoff delay capped at maxDelay, with up to
+// 20% random jitter added to avoid thundering-herd retries across shards.
+func backoffWithJitter(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base * time.Duration(1<<uint(attempt))
+	if delay > maxDelay || delay <= 0 {
+		delay = maxDelay
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay + jitter
+}
@@ -0,0 +1,111 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/streamingfast/dstore"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// DStoreSinkOptions configures DStoreSink's batching and destination store.
+type DStoreSinkOptions struct {
+	// StoreURL is any dstore-supported URL (local path, s3://, gs://, az://, ...).
+	StoreURL string
+	// BatchSize is the number of responses accumulated into a single object before it is
+	// uploaded.
+	BatchSize int
+
+	Logger *zap.Logger
+}
+
+// DStoreSink batches N Firehose responses into a single length-prefixed object and uploads
+// it through dstore, which is how the rest of this codebase already talks to S3/GCS/local
+// storage (see tools.CheckMergedBlocksBatch).
+type DStoreSink struct {
+	store dstore.Store
+	opts  DStoreSinkOptions
+	log   *zap.Logger
+
+	batch      []*pbfirehose.Response
+	batchCount int
+}
+
+func NewDStoreSink(opts DStoreSinkOptions) (*DStoreSink, error) {
+	store, err := dstore.NewStore(opts.StoreURL, "", "", false)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create dstore for %q: %w", opts.StoreURL, err)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+
+	return &DStoreSink{store: store, opts: opts, log: logger}, nil
+}
+
+func (s *DStoreSink) Write(ctx context.Context, response *pbfirehose.Response) error {
+	s.batch = append(s.batch, response)
+	if len(s.batch) >= s.opts.BatchSize {
+		return s.Flush(ctx)
+	}
+	return nil
+}
+
+func (s *DStoreSink) Flush(ctx context.Context) error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, response := range s.batch {
+		data, err := proto.Marshal(response)
+		if err != nil {
+			return fmt.Errorf("marshal response: %w", err)
+		}
+		if err := writeLengthPrefixed(buf, data); err != nil {
+			return fmt.Errorf("buffer batch: %w", err)
+		}
+	}
+
+	filename := fmt.Sprintf("%020d.batch", time.Now().UnixNano())
+	if err := s.store.WriteObject(ctx, filename, bytes.NewReader(buf.Bytes())); err != nil {
+		return fmt.Errorf("write batch object %s: %w", filename, err)
+	}
+
+	s.log.Info("uploaded batch", zap.String("filename", filename), zap.Int("count", len(s.batch)))
+
+	s.batch = s.batch[:0]
+	s.batchCount++
+	return nil
+}
+
+func (s *DStoreSink) Close() error {
+	return nil
+}
+
+func writeLengthPrefixed(buf *bytes.Buffer, data []byte) error {
+	length := uint32(len(data))
+	if err := buf.WriteByte(byte(length >> 24)); err != nil {
+		return err
+	}
+	if err := buf.WriteByte(byte(length >> 16)); err != nil {
+		return err
+	}
+	if err := buf.WriteByte(byte(length >> 8)); err != nil {
+		return err
+	}
+	if err := buf.WriteByte(byte(length)); err != nil {
+		return err
+	}
+	_, err := buf.Write(data)
+	return err
+}
@@ -0,0 +1,83 @@
+package sink
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/streamingfast/bstream"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// KafkaSinkOptions configures the Kafka producer used by KafkaSink.
+type KafkaSinkOptions struct {
+	Brokers []string
+	Topic   string
+	// RequiredAcks mirrors kafka.RequiredAcks: -1 (all), 0 (none) or 1 (leader only).
+	RequiredAcks kafka.RequiredAcks
+
+	Logger *zap.Logger
+}
+
+// KafkaSink produces one message per response to a Kafka topic, keyed by the big-endian
+// encoded block number so consumers can range-partition or compact on it.
+type KafkaSink struct {
+	writer *kafka.Writer
+	logger *zap.Logger
+}
+
+func NewKafkaSink(opts KafkaSinkOptions) *KafkaSink {
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(opts.Brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: opts.RequiredAcks,
+		},
+		logger: logger,
+	}
+}
+
+func (s *KafkaSink) Write(ctx context.Context, response *pbfirehose.Response) error {
+	blockNum, err := blockNumFromResponse(response)
+	if err != nil {
+		return fmt.Errorf("determine block number for kafka key: %w", err)
+	}
+
+	value, err := proto.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("marshal response: %w", err)
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, blockNum)
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   key,
+		Value: value,
+	})
+}
+
+// blockNumFromResponse extracts the block number from a Firehose response's opaque cursor,
+// which is the only field guaranteed to be populated regardless of chain-specific metadata.
+func blockNumFromResponse(response *pbfirehose.Response) (uint64, error) {
+	decodedCursor, err := bstream.CursorFromOpaque(response.Cursor)
+	if err != nil {
+		return 0, fmt.Errorf("decode cursor: %w", err)
+	}
+	return decodedCursor.Block.Num(), nil
+}
+
+func (s *KafkaSink) Flush(ctx context.Context) error { return nil }
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
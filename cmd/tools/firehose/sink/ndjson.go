@@ -0,0 +1,187 @@
+package sink
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/streamingfast/firehose-core/cmd/tools/print"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+)
+
+// NDJSONSinkOptions configures rotation for NDJSONSink. A new file is started whenever either
+// threshold is hit, whichever comes first.
+type NDJSONSinkOptions struct {
+	// Dir is the directory new NDJSON files are created in.
+	Dir string
+	// Prefix is prepended to every rotated file's name.
+	Prefix string
+	// MaxBytes rotates to a new file, before the next write, once the current file's
+	// uncompressed size has reached this value. Zero disables size-based rotation.
+	MaxBytes int64
+	// MaxAge rotates the current file once it has been open longer than this duration. Zero
+	// disables time-based rotation.
+	MaxAge time.Duration
+	// Gzip compresses each rotated file as it is written.
+	Gzip bool
+
+	Logger *zap.Logger
+}
+
+// NDJSONSink writes one JSON object per line to a file, rotating to a new file (optionally
+// gzip-compressed) on a size or time boundary.
+type NDJSONSink struct {
+	opts    NDJSONSinkOptions
+	printer print.OutputPrinter
+
+	currentFile   *os.File
+	currentGzip   *gzip.Writer
+	currentSize   int64
+	currentOpenAt time.Time
+}
+
+func NewNDJSONSink(opts NDJSONSinkOptions, printer print.OutputPrinter) *NDJSONSink {
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	return &NDJSONSink{opts: opts, printer: printer}
+}
+
+func (s *NDJSONSink) Write(ctx context.Context, response *pbfirehose.Response) error {
+	if s.shouldRotate() {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("rotate ndjson file: %w", err)
+		}
+	}
+	if s.currentFile == nil {
+		if err := s.openNewFile(); err != nil {
+			return fmt.Errorf("open ndjson file: %w", err)
+		}
+	}
+
+	line, err := s.encodeLine(response)
+	if err != nil {
+		return err
+	}
+
+	var n int
+	if s.opts.Gzip {
+		n, err = s.currentGzip.Write(line)
+	} else {
+		n, err = s.currentFile.Write(line)
+	}
+	if err != nil {
+		return fmt.Errorf("write ndjson line: %w", err)
+	}
+
+	s.currentSize += int64(n)
+	return nil
+}
+
+func (s *NDJSONSink) encodeLine(response *pbfirehose.Response) ([]byte, error) {
+	if s.printer != nil {
+		var buf []byte
+		w := bytesWriter{buf: &buf}
+		if err := s.printer.PrintTo(response, w); err != nil {
+			return nil, fmt.Errorf("print response: %w", err)
+		}
+		buf = append(buf, '\n')
+		return buf, nil
+	}
+
+	out, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("marshal response: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+func (s *NDJSONSink) shouldRotate() bool {
+	if s.currentFile == nil {
+		return false
+	}
+	if s.opts.MaxBytes > 0 && s.currentSize >= s.opts.MaxBytes {
+		return true
+	}
+	if s.opts.MaxAge > 0 && time.Since(s.currentOpenAt) >= s.opts.MaxAge {
+		return true
+	}
+	return false
+}
+
+func (s *NDJSONSink) rotate() error {
+	if err := s.closeCurrentFile(); err != nil {
+		return err
+	}
+	return s.openNewFile()
+}
+
+func (s *NDJSONSink) openNewFile() error {
+	if err := os.MkdirAll(s.opts.Dir, 0755); err != nil {
+		return fmt.Errorf("create ndjson directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%d.ndjson", s.opts.Prefix, time.Now().UnixNano())
+	if s.opts.Gzip {
+		name += ".gz"
+	}
+
+	file, err := os.Create(filepath.Join(s.opts.Dir, name))
+	if err != nil {
+		return err
+	}
+
+	s.currentFile = file
+	s.currentSize = 0
+	s.currentOpenAt = time.Now()
+	if s.opts.Gzip {
+		s.currentGzip = gzip.NewWriter(file)
+	}
+
+	s.opts.Logger.Info("rotated ndjson sink to new file", zap.String("file", file.Name()))
+	return nil
+}
+
+func (s *NDJSONSink) closeCurrentFile() error {
+	if s.currentFile == nil {
+		return nil
+	}
+
+	var err error
+	if s.currentGzip != nil {
+		err = s.currentGzip.Close()
+		s.currentGzip = nil
+	}
+	if closeErr := s.currentFile.Close(); err == nil {
+		err = closeErr
+	}
+	s.currentFile = nil
+	return err
+}
+
+func (s *NDJSONSink) Flush(ctx context.Context) error {
+	if s.currentGzip != nil {
+		return s.currentGzip.Flush()
+	}
+	return nil
+}
+
+func (s *NDJSONSink) Close() error {
+	return s.closeCurrentFile()
+}
+
+// bytesWriter adapts a *[]byte into an io.Writer so print.OutputPrinter can render directly
+// into an in-memory buffer we control the lifetime of.
+type bytesWriter struct {
+	buf *[]byte
+}
+
+func (w bytesWriter) Write(p []byte) (int, error) {
+	*w.buf = append(*w.buf, p...)
+	return len(p), nil
+}
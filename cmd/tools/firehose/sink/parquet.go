@@ -0,0 +1,269 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxParquetSchemaDepth bounds how deep schemaGroupForMessage recurses into nested message
+// fields, as a guard against the (in practice unseen, but not statically ruled out) case of a
+// chain's block message containing a field that transitively references itself.
+const maxParquetSchemaDepth = 16
+
+// BlockMessageDescriptor picks the chain's block message out of its BlockFileDescriptor(),
+// for use as ParquetSinkOptions.BlockDescriptor. A chain's block file typically declares a
+// single top-level message named after the block type (e.g. "Block"); when more than one is
+// present, the one whose name ends in "Block" is preferred.
+func BlockMessageDescriptor(fd protoreflect.FileDescriptor) (protoreflect.MessageDescriptor, error) {
+	messages := fd.Messages()
+	if messages.Len() == 0 {
+		return nil, fmt.Errorf("file descriptor %s declares no messages", fd.Path())
+	}
+
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if strings.HasSuffix(string(md.Name()), "Block") {
+			return md, nil
+		}
+	}
+
+	return messages.Get(0), nil
+}
+
+// ParquetSinkOptions configures ParquetSink's output file and row-group size.
+type ParquetSinkOptions struct {
+	// Path is the output .parquet file. ParquetSink owns the whole file (no rotation): split
+	// by range upstream if you need multiple files.
+	Path string
+	// RowGroupSize is the number of rows buffered before a row group is flushed to disk.
+	RowGroupSize int
+
+	// BlockDescriptor is the chain's own block message descriptor (chain.BlockFileDescriptor()'s
+	// block message), used to derive a Parquet schema with real nested, repeated columns (e.g.
+	// one row per block with a repeated "transactions" group) instead of an opaque blob.
+	BlockDescriptor protoreflect.MessageDescriptor
+
+	Logger *zap.Logger
+}
+
+// ParquetSink writes one row per block to a single Parquet file, using a schema derived from
+// the chain's own block message descriptor so nested, repeated fields (transactions, logs,
+// ...) become real Parquet columns instead of an opaque payload blob.
+type ParquetSink struct {
+	file   *os.File
+	writer *parquet.Writer
+	desc   protoreflect.MessageDescriptor
+	log    *zap.Logger
+}
+
+func NewParquetSink(opts ParquetSinkOptions) (*ParquetSink, error) {
+	if opts.BlockDescriptor == nil {
+		return nil, fmt.Errorf("parquet sink requires a block descriptor to derive its schema from")
+	}
+
+	file, err := os.Create(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("create parquet file %q: %w", opts.Path, err)
+	}
+
+	group, err := schemaGroupForMessage(opts.BlockDescriptor, 0)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("derive parquet schema from %s: %w", opts.BlockDescriptor.FullName(), err)
+	}
+	schema := parquet.NewSchema(string(opts.BlockDescriptor.Name()), group)
+
+	rowGroupSize := opts.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = 10_000
+	}
+
+	writer := parquet.NewWriter(file, schema, parquet.MaxRowsPerRowGroup(int64(rowGroupSize)))
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &ParquetSink{file: file, writer: writer, desc: opts.BlockDescriptor, log: logger}, nil
+}
+
+func (s *ParquetSink) Write(ctx context.Context, response *pbfirehose.Response) error {
+	blk := dynamicpb.NewMessage(s.desc)
+	if err := proto.Unmarshal(response.Block.Value, blk); err != nil {
+		return fmt.Errorf("decode block as %s: %w", s.desc.FullName(), err)
+	}
+
+	row := rowFromMessage(blk)
+	_, err := s.writer.Write(row)
+	return err
+}
+
+func (s *ParquetSink) Flush(ctx context.Context) error {
+	return s.writer.Flush()
+}
+
+func (s *ParquetSink) Close() error {
+	if err := s.writer.Close(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("close parquet writer: %w", err)
+	}
+	return s.file.Close()
+}
+
+// schemaGroupForMessage builds a parquet.Group mirroring md's fields: singular scalars become
+// optional leaves, repeated scalars become repeated leaves, singular/repeated messages become
+// (repeated) nested groups built the same way, and maps become a repeated {key, value} group.
+func schemaGroupForMessage(md protoreflect.MessageDescriptor, depth int) (parquet.Group, error) {
+	if depth > maxParquetSchemaDepth {
+		return nil, fmt.Errorf("exceeded max nested message depth (%d) at %s, possible self-referencing message", maxParquetSchemaDepth, md.FullName())
+	}
+
+	fields := md.Fields()
+	group := make(parquet.Group, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		node, err := schemaNodeForField(fd, depth)
+		if err != nil {
+			return nil, err
+		}
+		group[string(fd.Name())] = node
+	}
+	return group, nil
+}
+
+func schemaNodeForField(fd protoreflect.FieldDescriptor, depth int) (parquet.Node, error) {
+	if fd.IsMap() {
+		keyNode, err := parquetLeafForKind(fd.MapKey().Kind())
+		if err != nil {
+			return nil, fmt.Errorf("map key of field %s: %w", fd.FullName(), err)
+		}
+		valueNode, err := schemaNodeForField(fd.MapValue(), depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("map value of field %s: %w", fd.FullName(), err)
+		}
+		entry := parquet.Group{"key": keyNode, "value": parquet.Required(valueNode)}
+		return parquet.Repeated(entry), nil
+	}
+
+	var node parquet.Node
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		group, err := schemaGroupForMessage(fd.Message(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		node = group
+	default:
+		leaf, err := parquetLeafForKind(fd.Kind())
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fd.FullName(), err)
+		}
+		node = leaf
+	}
+
+	if fd.IsList() {
+		return parquet.Repeated(node), nil
+	}
+	return parquet.Optional(node), nil
+}
+
+func parquetLeafForKind(kind protoreflect.Kind) (parquet.Node, error) {
+	switch kind {
+	case protoreflect.BoolKind:
+		return parquet.Leaf(parquet.BooleanType), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return parquet.Int(32), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return parquet.Int(64), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return parquet.Uint(32), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return parquet.Uint(64), nil
+	case protoreflect.FloatKind:
+		return parquet.Leaf(parquet.FloatType), nil
+	case protoreflect.DoubleKind:
+		return parquet.Leaf(parquet.DoubleType), nil
+	case protoreflect.StringKind:
+		return parquet.String(), nil
+	case protoreflect.BytesKind:
+		return parquet.Leaf(parquet.ByteArrayType), nil
+	case protoreflect.EnumKind:
+		return parquet.String(), nil
+	default:
+		return nil, fmt.Errorf("unsupported proto kind %s", kind)
+	}
+}
+
+// rowFromMessage converts a decoded dynamicpb message into a map[string]any keyed by field
+// name, matching the shape schemaGroupForMessage derived for the same descriptor, which
+// parquet.Writer.Write accepts directly without requiring a static Go row type.
+func rowFromMessage(msg protoreflect.Message) map[string]any {
+	row := make(map[string]any)
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		row[string(fd.Name())] = parquetValueForField(fd, v)
+		return true
+	})
+	return row
+}
+
+func parquetValueForField(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.IsMap() {
+		m := v.Map()
+		entries := make([]map[string]any, 0, m.Len())
+		m.Range(func(mapKey protoreflect.MapKey, mapValue protoreflect.Value) bool {
+			entries = append(entries, map[string]any{
+				"key":   parquetScalarValue(fd.MapKey(), mapKey.Value()),
+				"value": parquetValueForKind(fd.MapValue(), mapValue),
+			})
+			return true
+		})
+		return entries
+	}
+
+	if fd.IsList() {
+		list := v.List()
+		values := make([]any, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			values[i] = parquetValueForKind(fd, list.Get(i))
+		}
+		return values
+	}
+
+	return parquetValueForKind(fd, v)
+}
+
+func parquetValueForKind(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return rowFromMessage(v.Message())
+	default:
+		return parquetScalarValue(fd, v)
+	}
+}
+
+// parquetScalarValue converts a single non-message field value to the Go type matching the
+// parquet.Node parquetLeafForKind built for fd's kind: enums are stored by name (since the
+// schema represents them as parquet.String()), everything else maps directly via v.Interface().
+func parquetScalarValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			return string(value.Name())
+		}
+		return fmt.Sprintf("%d", v.Enum())
+	case protoreflect.BytesKind:
+		return v.Bytes()
+	default:
+		return v.Interface()
+	}
+}
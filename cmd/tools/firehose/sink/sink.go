@@ -0,0 +1,24 @@
+// Package sink provides pluggable destinations for firehose-client's block stream. Each Sink
+// implementation owns its own buffering and persistence strategy, but they all honor the same
+// write/flush/close contract so the CLI can swap between them with a single --sink flag.
+package sink
+
+import (
+	"context"
+
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+)
+
+// Sink receives Firehose responses in stream order and persists them somewhere: stdout,
+// rotating NDJSON files, Parquet row groups, a Kafka topic, or a dstore-backed object store.
+type Sink interface {
+	// Write persists a single response. Implementations that batch (Parquet, dstore) may
+	// buffer internally and only flush once a batch boundary is reached.
+	Write(ctx context.Context, response *pbfirehose.Response) error
+	// Flush forces any buffered responses to be persisted. firehose-client calls it before
+	// each cursor checkpoint and once when the stream ends; after a failed Write or Flush,
+	// Close is called without a further Flush.
+	Flush(ctx context.Context) error
+	// Close releases any resources held by the sink (open files, network connections).
+	Close() error
+}
@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/streamingfast/firehose-core/cmd/tools/print"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+)
+
+// StdoutSink prints each response as JSON to the terminal, using the same print.OutputPrinter
+// already used elsewhere in the tools CLI. It is the default sink, preserving firehose-client's
+// historical behavior.
+type StdoutSink struct {
+	printer print.OutputPrinter
+}
+
+func NewStdoutSink(printer print.OutputPrinter) *StdoutSink {
+	return &StdoutSink{printer: printer}
+}
+
+func (s *StdoutSink) Write(ctx context.Context, response *pbfirehose.Response) error {
+	buffer := bytes.NewBuffer(nil)
+	if err := s.printer.PrintTo(response, buffer); err != nil {
+		return fmt.Errorf("print response: %w", err)
+	}
+
+	_, err := fmt.Fprintln(os.Stdout, buffer.String())
+	return err
+}
+
+func (s *StdoutSink) Flush(ctx context.Context) error { return nil }
+func (s *StdoutSink) Close() error                    { return nil }
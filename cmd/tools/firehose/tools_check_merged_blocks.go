@@ -0,0 +1,91 @@
+package firehose
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/streamingfast/cli/sflags"
+	firecore "github.com/streamingfast/firehose-core"
+	"github.com/streamingfast/firehose-core/tools"
+	"github.com/streamingfast/firehose-core/types"
+	"go.uber.org/zap"
+)
+
+func NewToolsCheckMergedBlocksBatchCmd[B firecore.Block](chain *firecore.Chain[B], zlog *zap.Logger) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check-merged-blocks-batch <source-store-url> <dest-store-url> <range>",
+		Short: "Scan a merged-blocks store for broken or missing bundles, writing markers to <dest-store-url>",
+		Args:  cobra.ExactArgs(3),
+		RunE:  createToolsCheckMergedBlocksBatchE(chain, zlog),
+		Example: firecore.ExamplePrefixed(chain, "tools check-merged-blocks-batch", `
+			# Adjust <url>s based on your actual network, writing .broken/.missing markers to ./markers
+			./merged-blocks ./markers 1000:2000
+
+			# Same, but auto-repair broken or missing bundles from a live Firehose endpoint
+			./merged-blocks ./markers 1000:2000 --repair-endpoint=mainnet.eth.streamingfast.io:443
+		`),
+	}
+
+	addFirehoseStreamClientFlagsToSet(cmd.Flags(), chain)
+	cmd.Flags().Int("file-block-size", 100, "Number of blocks per merged-blocks bundle")
+
+	cmd.Flags().String("repair-endpoint", "", "Firehose gRPC endpoint to re-fetch bundles from when a broken or missing one is found; repair is disabled when empty")
+	cmd.Flags().Bool("repair-dry-run", false, "When repairing, validate the re-fetched bundle but do not overwrite the source object")
+	cmd.Flags().Int("repair-max-attempts", 3, "Number of times to retry fetching a bundle from --repair-endpoint before giving up and falling back to a broken/missing marker")
+
+	return cmd
+}
+
+func createToolsCheckMergedBlocksBatchE[B firecore.Block](chain *firecore.Chain[B], zlog *zap.Logger) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		sourceStoreURL := args[0]
+		destStoreURL := args[1]
+
+		blockRange, err := types.GetBlockRangeFromArg(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid range %q: %w", args[2], err)
+		}
+
+		fileBlockSize := sflags.MustGetInt(cmd, "file-block-size")
+		if fileBlockSize <= 0 {
+			return fmt.Errorf("--file-block-size must be positive, got %d", fileBlockSize)
+		}
+
+		repair, repairConnClose, err := repairOptionsFromCmd(cmd, zlog, chain)
+		if err != nil {
+			return err
+		}
+		if repairConnClose != nil {
+			defer repairConnClose()
+		}
+
+		return tools.CheckMergedBlocksBatch(ctx, sourceStoreURL, destStoreURL, uint32(fileBlockSize), blockRange, repair)
+	}
+}
+
+// repairOptionsFromCmd builds a *tools.RepairOptions from --repair-endpoint/--repair-dry-run/
+// --repair-max-attempts, connecting to the repair endpoint with the same flags used for the
+// main Firehose stream client. It returns a nil *tools.RepairOptions, disabling repair, when
+// --repair-endpoint is left empty, in which case the returned close func is also nil.
+func repairOptionsFromCmd[B firecore.Block](cmd *cobra.Command, zlog *zap.Logger, chain *firecore.Chain[B]) (repair *tools.RepairOptions, connClose func(), err error) {
+	endpoint := sflags.MustGetString(cmd, "repair-endpoint")
+	if endpoint == "" {
+		return nil, nil, nil
+	}
+
+	firehoseClient, connClose, requestInfo, err := getFirehoseStreamClientFromCmd(cmd, zlog, endpoint, chain)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to connect to --repair-endpoint %q: %w", endpoint, err)
+	}
+
+	return &tools.RepairOptions{
+		Client:       firehoseClient,
+		GRPCCallOpts: requestInfo.GRPCCallOpts,
+		DryRun:       sflags.MustGetBool(cmd, "repair-dry-run"),
+		MaxAttempts:  sflags.MustGetInt(cmd, "repair-max-attempts"),
+		Logger:       zlog,
+	}, connClose, nil
+}
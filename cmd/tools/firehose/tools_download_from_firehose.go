@@ -3,13 +3,13 @@ package firehose
 import (
 	"context"
 	"fmt"
-	"io"
-	"time"
+	"sync"
 
 	"github.com/spf13/cobra"
 	"github.com/streamingfast/bstream"
 	pbbstream "github.com/streamingfast/bstream/pb/sf/bstream/v1"
 	"github.com/streamingfast/cli"
+	"github.com/streamingfast/cli/sflags"
 	"github.com/streamingfast/dstore"
 	firecore "github.com/streamingfast/firehose-core"
 	"github.com/streamingfast/firehose-core/types"
@@ -32,6 +32,7 @@ func NewToolsDownloadFromFirehoseCmd[B firecore.Block](chain *firecore.Chain[B],
 	}
 
 	addFirehoseStreamClientFlagsToSet(cmd.Flags(), chain)
+	cmd.Flags().Int("parallel-downloads", 1, "Number of concurrent bundle-aligned shards to stream in parallel")
 
 	return cmd
 }
@@ -53,7 +54,11 @@ func createToolsDownloadFromFirehoseE[B firecore.Block](chain *firecore.Chain[B]
 		}
 		defer connClose()
 
-		var retryDelay = time.Second * 4
+		concurrency := sflags.MustGetInt(cmd, "parallel-downloads")
+		if concurrency > 1 && requestInfo.Cursor != "" {
+			zlog.Warn("ignoring provided cursor because --parallel-downloads > 1 splits the range into independent shards that cannot resume from a single cursor", zap.Int("parallel_downloads", concurrency))
+			requestInfo.Cursor = ""
+		}
 
 		store, err := dstore.NewDBinStore(destFolder)
 		if err != nil {
@@ -66,108 +71,109 @@ func createToolsDownloadFromFirehoseE[B firecore.Block](chain *firecore.Chain[B]
 			Logger:     zlog,
 		}
 
-		approximateLIBWarningIssued := false
-		fallbackBlockTypeChecked := false
+		decoder := newResponseDecoder(chain, zlog)
 
 		var lastBlockID string
 		var lastBlockNum uint64
-		for {
+		write := func(blk *pbbstream.Block) error {
+			if lastBlockID != "" && blk.ParentId != lastBlockID {
+				return fmt.Errorf("got an invalid sequence of blocks: block %q has previousId %s, previous block %d had ID %q, this endpoint is serving blocks out of order", blk.String(), blk.ParentId, lastBlockNum, lastBlockID)
+			}
+			lastBlockID = blk.Id
+			lastBlockNum = blk.Number
+
+			return mergeWriter.ProcessBlock(blk, nil)
+		}
 
+		newStream := func(ctx context.Context, start, stop uint64) (BlocksStream, error) {
 			request := &pbfirehose.Request{
-				StartBlockNum:   blockRange.Start,
-				StopBlockNum:    blockRange.GetStopBlockOr(0),
+				StartBlockNum:   int64(start),
+				StopBlockNum:    stop,
 				FinalBlocksOnly: true,
 				Cursor:          requestInfo.Cursor,
 			}
 
 			stream, err := firehoseClient.Blocks(ctx, request, requestInfo.GRPCCallOpts...)
 			if err != nil {
-				return fmt.Errorf("unable to start blocks stream: %w", err)
+				return nil, fmt.Errorf("unable to start blocks stream: %w", err)
 			}
+			return stream, nil
+		}
 
-			for {
-				response, err := stream.Recv()
-				if err != nil {
-					if err == io.EOF {
-						return nil
-					}
-
-					zlog.Error("stream encountered a remote error, going to retry",
-						zap.Duration("retry_delay", retryDelay),
-						zap.Error(err),
-					)
-					<-time.After(retryDelay)
-					break
-				}
+		onProgress := func(shardIndex int, lastBlockNum uint64, stopBlockNum uint64) {
+			zlog.Debug("shard progress", zap.Int("shard", shardIndex), zap.Uint64("last_block_num", lastBlockNum), zap.Uint64("stop_block_num", stopBlockNum))
+		}
 
-				var blk *pbbstream.Block
-				if response.Metadata == nil {
-					if !fallbackBlockTypeChecked {
-						zlog.Warn("the server endpoint you are trying to download from is too old to support 'download-from-firehose', contact the provider so they update their Firehose server to a more recent version")
-						if _, ok := chain.BlockFactory().(*pbbstream.Block); ok {
-							return fmt.Errorf("this tool only works with blocks that are **not** of type *pbbstream.Block")
-						}
-
-						fallbackBlockTypeChecked = true
-					}
-
-					block := chain.BlockFactory()
-					if err := anypb.UnmarshalTo(response.Block, block, proto.UnmarshalOptions{}); err != nil {
-						return fmt.Errorf("unmarshal response block: %w", err)
-					}
-
-					if _, ok := block.(firecore.BlockLIBNumDerivable); !ok {
-						// We must wrap the block in a BlockEnveloppe and "provide" the LIB number as itself minus 1 since
-						// there is nothing we can do more here to obtain the value sadly. For chain where the LIB can be
-						// derived from the Block itself, this code does **not** run (so it will have the correct value)
-						if !approximateLIBWarningIssued {
-							approximateLIBWarningIssued = true
-							zlog.Warn("LIB number is approximated, it is not provided by the chain's Block model so we msut set it to block number minus 1 (which is kinda ok because only final blocks are retrieved in this download tool)")
-						}
-
-						number := block.GetFirehoseBlockNumber()
-						libNum := number - 1
-						if number <= bstream.GetProtocolFirstStreamableBlock {
-							libNum = number
-						}
-
-						block = firecore.BlockEnveloppe{
-							Block:  block,
-							LIBNum: libNum,
-						}
-					}
-
-					blk, err = chain.BlockEncoder.Encode(block)
-					if err != nil {
-						return fmt.Errorf("error decoding response to bstream block: %w", err)
-					}
-				} else {
-					decodedCursor, err := bstream.CursorFromOpaque(response.Cursor)
-					if err != nil {
-						return fmt.Errorf("error decoding response cursor: %w", err)
-					}
-
-					blk = &pbbstream.Block{
-						Id:        decodedCursor.Block.ID(),
-						Number:    decodedCursor.Block.Num(),
-						ParentId:  response.Metadata.ParentId,
-						ParentNum: response.Metadata.ParentNum,
-						Timestamp: response.Metadata.Time,
-						LibNum:    response.Metadata.LibNum,
-						Payload:   response.Block,
-					}
-				}
+		manager := NewDownloadManager(chain, zlog, concurrency, newStream, decoder, write, onProgress)
+
+		return manager.Run(ctx, blockRange.Start, blockRange.GetStopBlockOr(0))
+	}
+}
 
-				if lastBlockID != "" && blk.ParentId != lastBlockID {
-					return fmt.Errorf("got an invalid sequence of blocks: block %q has previousId %s, previous block %d had ID %q, this endpoint is serving blocks out of order", blk.String(), blk.ParentId, lastBlockNum, lastBlockID)
+// newResponseDecoder returns the DecodeFunc used to turn a raw firehose Response into a
+// bstream Block, preserving the pre-sharding fallback behavior for old Firehose servers that
+// don't populate response.Metadata. The returned DecodeFunc is invoked concurrently by every
+// shard worker goroutine in DownloadManager, so the two "warn once" flags below are guarded by
+// sync.Once rather than plain bools.
+func newResponseDecoder[B firecore.Block](chain *firecore.Chain[B], zlog *zap.Logger) DecodeFunc {
+	var approximateLIBWarningIssued sync.Once
+	var fallbackBlockTypeChecked sync.Once
+	_, unsupportedBlockType := chain.BlockFactory().(*pbbstream.Block)
+
+	return func(response *pbfirehose.Response) (*pbbstream.Block, error) {
+		if response.Metadata == nil {
+			fallbackBlockTypeChecked.Do(func() {
+				zlog.Warn("the server endpoint you are trying to download from is too old to support 'download-from-firehose', contact the provider so they update their Firehose server to a more recent version")
+			})
+			if unsupportedBlockType {
+				return nil, fmt.Errorf("this tool only works with blocks that are **not** of type *pbbstream.Block")
+			}
+
+			block := chain.BlockFactory()
+			if err := anypb.UnmarshalTo(response.Block, block, proto.UnmarshalOptions{}); err != nil {
+				return nil, fmt.Errorf("unmarshal response block: %w", err)
+			}
+
+			if _, ok := block.(firecore.BlockLIBNumDerivable); !ok {
+				// We must wrap the block in a BlockEnveloppe and "provide" the LIB number as itself minus 1 since
+				// there is nothing we can do more here to obtain the value sadly. For chain where the LIB can be
+				// derived from the Block itself, this code does **not** run (so it will have the correct value)
+				approximateLIBWarningIssued.Do(func() {
+					zlog.Warn("LIB number is approximated, it is not provided by the chain's Block model so we msut set it to block number minus 1 (which is kinda ok because only final blocks are retrieved in this download tool)")
+				})
+
+				number := block.GetFirehoseBlockNumber()
+				libNum := number - 1
+				if number <= bstream.GetProtocolFirstStreamableBlock {
+					libNum = number
 				}
-				lastBlockID = blk.Id
-				lastBlockNum = blk.Number
 
-				if err := mergeWriter.ProcessBlock(blk, nil); err != nil {
-					return fmt.Errorf("write to blockwriter: %w", err)
+				block = firecore.BlockEnveloppe{
+					Block:  block,
+					LIBNum: libNum,
 				}
 			}
+
+			blk, err := chain.BlockEncoder.Encode(block)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding response to bstream block: %w", err)
+			}
+			return blk, nil
 		}
+
+		decodedCursor, err := bstream.CursorFromOpaque(response.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding response cursor: %w", err)
+		}
+
+		return &pbbstream.Block{
+			Id:        decodedCursor.Block.ID(),
+			Number:    decodedCursor.Block.Num(),
+			ParentId:  response.Metadata.ParentId,
+			ParentNum: response.Metadata.ParentNum,
+			Timestamp: response.Metadata.Time,
+			LibNum:    response.Metadata.LibNum,
+			Payload:   response.Block,
+		}, nil
 	}
 }
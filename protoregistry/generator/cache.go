@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultCacheDir returns $XDG_CACHE_HOME/firehose-core/wkr, falling back to the OS default
+// user cache directory (os.UserCacheDir already honors XDG_CACHE_HOME on Linux).
+func defaultCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine user cache directory: %w", err)
+	}
+	return filepath.Join(base, "firehose-core", "wkr"), nil
+}
+
+// moduleCache is an on-disk cache of FileDescriptorSet bytes fetched from buf.build, keyed by
+// module name only: it holds one entry per module, the last one fetched, and a new fetch
+// replaces it. The content digest of an entry is computed, not used as its key. It lets regeneration skip the network round-trip (and the
+// BUFBUILD_AUTH_TOKEN requirement) entirely when the caller is fine reusing whatever was last
+// fetched, which is what --offline builds need.
+type moduleCache struct {
+	dir string
+}
+
+func newModuleCache(dir string) (*moduleCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache directory %q: %w", dir, err)
+	}
+	return &moduleCache{dir: dir}, nil
+}
+
+func (c *moduleCache) path(module string) string {
+	return filepath.Join(c.dir, cacheKey(module)+".binpb")
+}
+
+// etagPath is the sidecar file recording the ETag buf.build returned for module's cached
+// entry, so a later run can send it back as If-None-Match instead of trusting the cache blind.
+func (c *moduleCache) etagPath(module string) string {
+	return filepath.Join(c.dir, cacheKey(module)+".etag")
+}
+
+// cacheKey turns a module reference like "buf.build/streamingfast/firehose-ethereum" into a
+// filesystem-safe key.
+func cacheKey(module string) string {
+	return strings.ReplaceAll(module, "/", "_")
+}
+
+// Get returns the cached FileDescriptorSet bytes for module, if present.
+func (c *moduleCache) Get(module string) (data []byte, ok bool, err error) {
+	data, err = os.ReadFile(c.path(module))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("read cache entry for %s: %w", module, err)
+	}
+	return data, true, nil
+}
+
+// GetETag returns the ETag recorded alongside module's cache entry, if any. A module cached
+// before this sidecar existed, or whose ETag is unknown, simply has no entry, in which case
+// ok is false and the caller should fall back to an unconditional fetch.
+func (c *moduleCache) GetETag(module string) (etag string, ok bool, err error) {
+	data, err := os.ReadFile(c.etagPath(module))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("read cached etag for %s: %w", module, err)
+	}
+	return string(data), true, nil
+}
+
+// Put stores data as module's cache entry, alongside etag (may be empty if the server didn't
+// return one), and returns its content digest, to be recorded in the lockfile.
+func (c *moduleCache) Put(module string, data []byte, etag string) (digest string, err error) {
+	if err := os.WriteFile(c.path(module), data, 0o644); err != nil {
+		return "", fmt.Errorf("write cache entry for %s: %w", module, err)
+	}
+	if etag != "" {
+		if err := os.WriteFile(c.etagPath(module), []byte(etag), 0o644); err != nil {
+			return "", fmt.Errorf("write cached etag for %s: %w", module, err)
+		}
+	}
+	return contentDigest(data), nil
+}
+
+func contentDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// lockfile records, for each module resolved during generation, the content digest that was
+// actually embedded in the generated Go file, so downstream reproducible builds can pin the
+// exact proto versions used.
+type lockfile struct {
+	Modules map[string]string `json:"modules"`
+}
+
+func writeLockfile(path string, modules map[string]string) error {
+	data, err := json.MarshalIndent(lockfile{Modules: modules}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal lockfile: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write lockfile %q: %w", path, err)
+	}
+	return nil
+}
+
+// lockfilePath derives the lockfile's path from the generated output file: foo.go -> foo.lock.json.
+// Writing to stdout ("-") has no natural sibling path, so the lockfile is skipped in that case.
+func lockfilePath(output string) string {
+	if output == "-" {
+		return ""
+	}
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + ".lock.json"
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"embed"
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -35,36 +36,72 @@ var wellKnownProtoRepos = []string{
 }
 
 func main() {
-	cli.Ensure(len(os.Args) == 3, "go run ./generator <output_file> <package_name>")
+	offline := flag.Bool("offline", false, "Fail instead of calling buf.build when a module is not already cached")
+	refresh := flag.Bool("refresh", false, "Bypass the cache and re-fetch every module from buf.build")
+	revalidate := flag.Bool("revalidate", false, "For a cached module, send a conditional If-None-Match request to buf.build to check it is still current, instead of trusting the cache as-is")
+	modulesFile := flag.String("modules-file", "", "Read the well-known proto repos list from this YAML or JSON file instead of the hardcoded default")
+	cacheDirFlag := flag.String("cache-dir", "", "Module cache directory, one entry per module name (default $XDG_CACHE_HOME/firehose-core/wkr)")
+	flag.Parse()
 
-	authToken := os.Getenv("BUFBUILD_AUTH_TOKEN")
-	if authToken == "" {
-		log.Fatalf("Please set the BUFBUILD_AUTH_TOKEN environment variable, to generate well known registry")
-		return
+	args := flag.Args()
+	cli.Ensure(len(args) == 2, "go run ./generator [flags] <output_file> <package_name>")
+
+	output := args[0]
+	packageName := args[1]
+
+	if *offline && *refresh {
+		log.Fatalf("--offline and --refresh are mutually exclusive")
+	}
+	if *offline && *revalidate {
+		log.Fatalf("--offline and --revalidate are mutually exclusive")
 	}
 
-	output := os.Args[1]
-	packageName := os.Args[2]
+	modules := wellKnownProtoRepos
+	if *modulesFile != "" {
+		var err error
+		modules, err = loadModulesFile(*modulesFile)
+		cli.NoError(err, "Unable to load --modules-file")
+	}
 
-	client := reflectv1beta1connect.NewFileDescriptorSetServiceClient(
-		http.DefaultClient,
-		"https://buf.build",
-	)
+	cacheDir := *cacheDirFlag
+	if cacheDir == "" {
+		var err error
+		cacheDir, err = defaultCacheDir()
+		cli.NoError(err, "Unable to determine cache directory")
+	}
+	cache, err := newModuleCache(cacheDir)
+	cli.NoError(err, "Unable to open module cache")
 
 	var protofiles []ProtoFile
+	digests := make(map[string]string, len(modules))
+
+	var client reflectv1beta1connect.FileDescriptorSetServiceClient
+	var transport *statusCapturingTransport
+	var authToken string
 
-	for _, wellKnownProtoRepo := range wellKnownProtoRepos {
-		request := connect.NewRequest(&reflectv1beta1.GetFileDescriptorSetRequest{
-			Module: wellKnownProtoRepo,
+	for _, wellKnownProtoRepo := range modules {
+		data, digest, err := resolveModule(cache, wellKnownProtoRepo, *offline, *refresh, *revalidate, func(ifNoneMatch string) ([]byte, string, bool, error) {
+			if client == nil {
+				authToken = os.Getenv("BUFBUILD_AUTH_TOKEN")
+				if authToken == "" {
+					log.Fatalf("Please set the BUFBUILD_AUTH_TOKEN environment variable, to generate well known registry")
+				}
+				transport = &statusCapturingTransport{base: http.DefaultTransport}
+				client = reflectv1beta1connect.NewFileDescriptorSetServiceClient(
+					&http.Client{Transport: transport},
+					"https://buf.build",
+					connect.WithHTTPGet(),
+				)
+			}
+			return fetchFileDescriptorSet(client, transport, authToken, wellKnownProtoRepo, ifNoneMatch)
 		})
-		request.Header().Set("Authorization", "Bearer "+authToken)
-		fileDescriptorSet, err := client.GetFileDescriptorSet(context.Background(), request)
-		if err != nil {
-			log.Fatalf("failed to call file descriptor set service: %v", err)
-			return
-		}
+		cli.NoError(err, "Unable to resolve module %s", wellKnownProtoRepo)
+		digests[wellKnownProtoRepo] = digest
+
+		fileDescriptorSet := &reflectv1beta1.GetFileDescriptorSetResponse{}
+		cli.NoError(proto.Unmarshal(data, fileDescriptorSet), "Unable to decode cached file descriptor set for %s", wellKnownProtoRepo)
 
-		for _, file := range fileDescriptorSet.Msg.FileDescriptorSet.File {
+		for _, file := range fileDescriptorSet.FileDescriptorSet.File {
 			cnt, err := proto.Marshal(file)
 			if err != nil {
 				log.Fatalf("failed to marshall proto file %s: %v", file.GetName(), err)
@@ -76,8 +113,6 @@ func main() {
 			}
 			protofiles = append(protofiles, ProtoFile{name, cnt})
 		}
-		// avoid hitting the buf.build rate limit
-		time.Sleep(1 * time.Second)
 	}
 
 	tmpl, err := template.New("wellknown").Funcs(templateFunctions()).ParseFS(templates, "*.gotmpl")
@@ -105,9 +140,125 @@ func main() {
 	})
 	cli.NoError(err, "Unable to render template")
 
+	if path := lockfilePath(output); path != "" {
+		cli.NoError(writeLockfile(path, digests), "Unable to write lockfile")
+	}
+
 	fmt.Println("Done creating well known registry")
 }
 
+// resolveModule returns the cached FileDescriptorSet bytes for module, along with its content
+// digest, calling fetch only when required: on a cache miss, when refresh is set, or when
+// revalidate is set (in which case fetch is still called, but with the cached entry's ETag as
+// an If-None-Match-style precondition, so a server confirming nothing changed costs a cheap
+// round trip instead of a full re-fetch). With a cache entry present and neither refresh nor
+// revalidate set, the cache is trusted outright and fetch is never called. offline turns a
+// cache miss into a hard error instead of falling back to the network, and always trusts
+// whatever is cached over calling fetch.
+func resolveModule(cache *moduleCache, module string, offline, refresh, revalidate bool, fetch func(ifNoneMatch string) (data []byte, etag string, notModified bool, err error)) (data []byte, digest string, err error) {
+	cached, hasCached, err := cache.Get(module)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if hasCached && !refresh {
+		if offline || !revalidate {
+			return cached, contentDigest(cached), nil
+		}
+
+		ifNoneMatch, _, err := cache.GetETag(module)
+		if err != nil {
+			return nil, "", err
+		}
+
+		data, etag, notModified, err := fetch(ifNoneMatch)
+		if err != nil {
+			return nil, "", err
+		}
+		time.Sleep(1 * time.Second) // avoid hitting the buf.build rate limit
+
+		if notModified {
+			return cached, contentDigest(cached), nil
+		}
+
+		digest, err = cache.Put(module, data, etag)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, digest, nil
+	}
+
+	if offline {
+		return nil, "", fmt.Errorf("module %s is not cached and --offline was set", module)
+	}
+
+	data, etag, _, err := fetch("")
+	if err != nil {
+		return nil, "", err
+	}
+
+	// avoid hitting the buf.build rate limit
+	time.Sleep(1 * time.Second)
+
+	digest, err = cache.Put(module, data, etag)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, digest, nil
+}
+
+// statusCapturingTransport wraps an http.RoundTripper and records the most recent response's
+// HTTP status code, so fetchFileDescriptorSet can check for a real "304 Not Modified" by its
+// typed status rather than string-matching connect-go's (bodyless-response) error text.
+type statusCapturingTransport struct {
+	base       http.RoundTripper
+	lastStatus int
+}
+
+func (t *statusCapturingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if resp != nil {
+		t.lastStatus = resp.StatusCode
+	} else {
+		t.lastStatus = 0
+	}
+	return resp, err
+}
+
+// fetchFileDescriptorSet calls buf.build's reflect service and returns the raw, marshalled
+// GetFileDescriptorSetResponse so it can be cached byte-for-byte and replayed later without
+// re-parsing it differently than a live response would be, along with the response's ETag.
+//
+// When ifNoneMatch is non-empty, it is sent as the If-None-Match header; the client is
+// configured with connect.WithHTTPGet() so this unary, side-effect-free call rides over a
+// plain HTTP GET, which lets buf.build's edge answer with a 304 when the module hasn't
+// changed. transport must be the statusCapturingTransport backing client's *http.Client, so a
+// 304 can be recognized by its actual status code instead of guessing at how connect-go
+// formats the resulting (bodyless-response) error.
+func fetchFileDescriptorSet(client reflectv1beta1connect.FileDescriptorSetServiceClient, transport *statusCapturingTransport, authToken, module, ifNoneMatch string) (data []byte, etag string, notModified bool, err error) {
+	request := connect.NewRequest(&reflectv1beta1.GetFileDescriptorSetRequest{
+		Module: module,
+	})
+	request.Header().Set("Authorization", "Bearer "+authToken)
+	if ifNoneMatch != "" {
+		request.Header().Set("If-None-Match", ifNoneMatch)
+	}
+
+	response, err := client.GetFileDescriptorSet(context.Background(), request)
+	if err != nil {
+		if ifNoneMatch != "" && transport.lastStatus == http.StatusNotModified {
+			return nil, "", true, nil
+		}
+		return nil, "", false, fmt.Errorf("failed to call file descriptor set service: %w", err)
+	}
+
+	data, err = proto.Marshal(response.Msg)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to marshal file descriptor set response: %w", err)
+	}
+	return data, response.Header().Get("Etag"), false, nil
+}
+
 type ProtoFile struct {
 	Name string
 	Data []byte
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadModulesFile reads the wellKnownProtoRepos list from a YAML or JSON file instead of
+// using the hardcoded slice, picking the format based on the file extension. The file is
+// expected to contain a plain list of buf.build module references, e.g.:
+//
+//   - buf.build/streamingfast/firehose-ethereum
+//   - buf.build/streamingfast/firehose-near
+func loadModulesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read modules file %q: %w", path, err)
+	}
+
+	var modules []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &modules); err != nil {
+			return nil, fmt.Errorf("parse modules file %q as YAML: %w", path, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, &modules); err != nil {
+			return nil, fmt.Errorf("parse modules file %q as JSON: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("modules file %q must end in .yaml, .yml or .json", path)
+	}
+
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("modules file %q contains no modules", path)
+	}
+
+	return modules, nil
+}
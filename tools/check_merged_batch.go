@@ -15,12 +15,18 @@ import (
 // broken merged-blocks-files are the ones that contain "empty" blocks (no ID) or unlinkable blocks
 // there could be false positives on unlinkable blocks, though
 // output files are like this: 0000123100.broken  0000123500.missing
+//
+// When repair is non-nil, a bundle that would otherwise be marked `.broken` or `.missing` is
+// instead re-fetched from repair's Firehose endpoint and, once revalidated, written in place
+// of the source object, so a single pass can fix contiguous corruption without operator
+// intervention. The walk resumes with the freshly repaired bundle's last hash.
 func CheckMergedBlocksBatch(
 	ctx context.Context,
 	sourceStoreURL string,
 	destStoreURL string,
 	fileBlockSize uint32,
 	blockRange BlockRange,
+	repair *RepairOptions,
 ) error {
 	if !blockRange.IsResolved() {
 		return fmt.Errorf("check merged blocks can only work with fully resolved range, got %s", blockRange)
@@ -60,7 +66,18 @@ func CheckMergedBlocksBatch(
 			return fmt.Errorf("unhandled error: found base number %d below expected %d", baseNum, expected)
 		}
 		for expected < baseNum {
-			outputFile := fmt.Sprintf("%010d.missing", expected)
+			missingFilename := fmt.Sprintf("%010d", expected)
+			if repair != nil {
+				repairedHash, repairErr := repair.repairBundle(ctx, blocksStore, missingFilename, expected, fileBlockSize, lastBlockHash)
+				if repairErr == nil {
+					lastBlockHash = repairedHash
+					expected += fileBlockSize64
+					continue
+				}
+				fmt.Printf("repair of missing file %s failed, falling back to marker: %v\n", missingFilename, repairErr)
+			}
+
+			outputFile := missingFilename + ".missing"
 			fmt.Printf("found missing file %s, writing to store\n", outputFile)
 			destStore.WriteObject(ctx, outputFile, strings.NewReader(""))
 			expected += fileBlockSize64
@@ -68,10 +85,22 @@ func CheckMergedBlocksBatch(
 
 		broken, lastHash, err := checkMergedBlockFileBroken(ctx, blocksStore, filename, lastBlockHash)
 		if broken {
-			outputFile := fmt.Sprintf("%010d.broken", baseNum)
-			fmt.Printf("found broken file %s, writing to store\n", outputFile)
-			destStore.WriteObject(ctx, outputFile, strings.NewReader(""))
-			lastBlockHash = ""
+			if repair != nil {
+				repairedHash, repairErr := repair.repairBundle(ctx, blocksStore, filename, uint64(baseNum), fileBlockSize, lastBlockHash)
+				if repairErr == nil {
+					lastBlockHash = repairedHash
+					broken = false
+				} else {
+					fmt.Printf("repair of broken file %s failed, falling back to marker: %v\n", filename, repairErr)
+				}
+			}
+
+			if broken {
+				outputFile := fmt.Sprintf("%010d.broken", baseNum)
+				fmt.Printf("found broken file %s, writing to store\n", outputFile)
+				destStore.WriteObject(ctx, outputFile, strings.NewReader(""))
+				lastBlockHash = ""
+			}
 		} else {
 			lastBlockHash = lastHash
 		}
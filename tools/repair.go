@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/bstream/pb/sf/bstream/v1"
+	"github.com/streamingfast/dstore"
+	firecore "github.com/streamingfast/firehose-core"
+	pbfirehose "github.com/streamingfast/pbgo/sf/firehose/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// defaultRepairMaxAttempts is used whenever RepairOptions.MaxAttempts is left at its zero
+// value, so an unconfigured RepairOptions cannot silently skip the retry loop and fall
+// straight through to overwriting the source object with an unvalidated fetch.
+const defaultRepairMaxAttempts = 1
+
+// RepairOptions configures the auto-repair behavior of CheckMergedBlocksBatch: instead of
+// writing a `.broken` or `.missing` marker for a bundle, it re-fetches that bundle's block
+// range from a Firehose endpoint, revalidates it, and overwrites the source object in place.
+type RepairOptions struct {
+	// Client is an already-connected Firehose client pointed at the configured --repair-endpoint.
+	Client pbfirehose.FirehoseClient
+	// GRPCCallOpts are forwarded to every repair call (auth headers, compression, etc).
+	GRPCCallOpts []grpc.CallOption
+	// DryRun, when true, performs the re-fetch and validation but does not overwrite the
+	// source object, logging what would have been repaired instead.
+	DryRun bool
+	// MaxAttempts bounds how many times a single bundle is re-fetched before giving up and
+	// falling back to writing the usual `.broken`/`.missing` marker. Values <= 0 are treated
+	// as defaultRepairMaxAttempts.
+	MaxAttempts int
+
+	Logger *zap.Logger
+}
+
+func (o *RepairOptions) logger() *zap.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return zap.NewNop()
+}
+
+func (o *RepairOptions) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return defaultRepairMaxAttempts
+	}
+	return o.MaxAttempts
+}
+
+// repairBundle re-fetches the merged-blocks bundle starting at baseNum (fileBlockSize blocks
+// wide) from o.Client, validates it end-to-end and, unless o.DryRun is set, overwrites
+// filename in blocksStore via a `.tmp` staging object; the overwrite is not atomic (see
+// stageAndSwap). It returns the last block
+// hash of the repaired bundle, to be used as the expected parent hash for the next bundle.
+func (o *RepairOptions) repairBundle(
+	ctx context.Context,
+	blocksStore dstore.Store,
+	filename string,
+	baseNum uint64,
+	fileBlockSize uint32,
+	expectedParentHash string,
+) (lastHash string, err error) {
+	stopNum := baseNum + uint64(fileBlockSize)
+
+	var blocks []*pbbstream.Block
+	var lastErr error
+	maxAttempts := o.maxAttempts()
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		blocks, lastHash, lastErr = o.fetchAndValidateBundle(ctx, baseNum, stopNum, expectedParentHash)
+		if lastErr == nil {
+			break
+		}
+
+		o.logger().Warn("repair attempt failed, retrying",
+			zap.String("filename", filename),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", maxAttempts),
+			zap.Error(lastErr),
+		)
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("repair bundle %s: exhausted %d attempts: %w", filename, maxAttempts, lastErr)
+	}
+
+	if o.DryRun {
+		o.logger().Info("dry-run: would have repaired bundle", zap.String("filename", filename), zap.Int("block_count", len(blocks)))
+		return lastHash, nil
+	}
+
+	if err := o.stageAndSwap(ctx, blocksStore, filename, baseNum, blocks); err != nil {
+		return "", fmt.Errorf("repair bundle %s: %w", filename, err)
+	}
+
+	return lastHash, nil
+}
+
+// fetchAndValidateBundle streams [baseNum, stopNum) from the repair endpoint, decodes every
+// response into a *pbbstream.Block and checks that each one is present (has an ID) and that
+// the ParentId chain links together, starting from expectedParentHash when it is known. The
+// decoded blocks are returned so the caller can encode them into the merged-blocks dbin
+// format without streaming the range a second time.
+func (o *RepairOptions) fetchAndValidateBundle(ctx context.Context, baseNum, stopNum uint64, expectedParentHash string) (blocks []*pbbstream.Block, lastHash string, err error) {
+	request := &pbfirehose.Request{
+		StartBlockNum:   int64(baseNum),
+		StopBlockNum:    stopNum,
+		FinalBlocksOnly: true,
+	}
+
+	stream, err := o.Client.Blocks(ctx, request, o.GRPCCallOpts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to start repair stream: %w", err)
+	}
+
+	lastHash = expectedParentHash
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, "", fmt.Errorf("repair stream error: %w", err)
+		}
+
+		blk, err := decodeRepairResponse(response)
+		if err != nil {
+			return nil, "", fmt.Errorf("decode repaired block: %w", err)
+		}
+
+		if blk.Id == "" {
+			return nil, "", fmt.Errorf("repaired block %d has no ID", blk.Number)
+		}
+		if lastHash != "" && blk.ParentId != lastHash {
+			return nil, "", fmt.Errorf("repaired block %d does not link to expected parent hash %q", blk.Number, lastHash)
+		}
+
+		blocks = append(blocks, blk)
+		lastHash = blk.Id
+	}
+
+	if len(blocks) == 0 {
+		return nil, "", fmt.Errorf("repair endpoint returned no blocks for range [%d, %d)", baseNum, stopNum)
+	}
+
+	return blocks, lastHash, nil
+}
+
+// decodeRepairResponse turns a raw firehose Response into a bstream Block. Unlike
+// download-from-firehose, auto-repair requires a modern Firehose server that populates
+// response.Metadata: falling back to a chain-specific block factory would require threading
+// a chain type parameter through CheckMergedBlocksBatch, which isn't worth it for a repair
+// path that can simply ask the operator to point --repair-endpoint at an up-to-date server.
+func decodeRepairResponse(response *pbfirehose.Response) (*pbbstream.Block, error) {
+	if response.Metadata == nil {
+		return nil, fmt.Errorf("repair endpoint did not return block metadata, it is too old to support auto-repair; contact the provider so they update their Firehose server to a more recent version")
+	}
+
+	decodedCursor, err := bstream.CursorFromOpaque(response.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("decode response cursor: %w", err)
+	}
+
+	return &pbbstream.Block{
+		Id:        decodedCursor.Block.ID(),
+		Number:    decodedCursor.Block.Num(),
+		ParentId:  response.Metadata.ParentId,
+		ParentNum: response.Metadata.ParentNum,
+		Timestamp: response.Metadata.Time,
+		LibNum:    response.Metadata.LibNum,
+		Payload:   response.Block,
+	}, nil
+}
+
+// stageAndSwap encodes blocks into a proper dbin merged-blocks bundle (via the same
+// firecore.MergedBlocksWriter encoder download-from-firehose uses), stages it as
+// `filename + ".tmp"` in blocksStore, then overwrites filename by reading the staged object
+// back and writing it through. dstore.Store does not expose an atomic rename in general, so
+// this is not a true atomic swap: if the process dies mid-write to filename, the live bundle
+// can still be left truncated. What the `.tmp` staging step does buy us is a verified-written
+// copy of the new bytes surviving in the store under a name future repair passes ignore
+// (WalkFrom already skips `.tmp` objects), so a crash here can be manually recovered from by
+// renaming the `.tmp` object over filename, instead of only having the bytes in this process's
+// memory.
+func (o *RepairOptions) stageAndSwap(ctx context.Context, blocksStore dstore.Store, filename string, baseNum uint64, blocks []*pbbstream.Block) error {
+	encoded, err := encodeMergedBlocksBundle(o.logger(), baseNum, blocks)
+	if err != nil {
+		return fmt.Errorf("encode repaired bundle: %w", err)
+	}
+
+	tmpFilename := filename + ".tmp"
+	if err := blocksStore.WriteObject(ctx, tmpFilename, bytes.NewReader(encoded)); err != nil {
+		return fmt.Errorf("write staging object %s: %w", tmpFilename, err)
+	}
+
+	staged, err := blocksStore.OpenObject(ctx, tmpFilename)
+	if err != nil {
+		return fmt.Errorf("open staging object %s: %w", tmpFilename, err)
+	}
+	defer staged.Close()
+
+	if err := blocksStore.WriteObject(ctx, filename, staged); err != nil {
+		return fmt.Errorf("overwrite %s from staging object: %w", filename, err)
+	}
+
+	if err := blocksStore.DeleteObject(ctx, tmpFilename); err != nil {
+		o.logger().Warn("failed to clean up staging object, will be ignored by future walks", zap.String("tmp_filename", tmpFilename), zap.Error(err))
+	}
+
+	return nil
+}
+
+// encodeMergedBlocksBundle encodes blocks into the dbin-framed merged-blocks format that
+// checkMergedBlockFileBroken (via bstream.GetBlockReaderFactory) expects to read back, by
+// routing them through a firecore.MergedBlocksWriter pointed at a scratch directory and
+// reading back the single bundle file it produces.
+func encodeMergedBlocksBundle(logger *zap.Logger, baseNum uint64, blocks []*pbbstream.Block) ([]byte, error) {
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no blocks to encode")
+	}
+
+	tmpDir, err := os.MkdirTemp("", "firehose-core-repair-*")
+	if err != nil {
+		return nil, fmt.Errorf("create scratch directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	scratchStore, err := dstore.NewDBinStore(tmpDir)
+	if err != nil {
+		return nil, fmt.Errorf("open scratch store: %w", err)
+	}
+
+	mergeWriter := &firecore.MergedBlocksWriter{
+		Store:      scratchStore,
+		TweakBlock: func(b *pbbstream.Block) (*pbbstream.Block, error) { return b, nil },
+		Logger:     logger,
+	}
+
+	for _, blk := range blocks {
+		if err := mergeWriter.ProcessBlock(blk, nil); err != nil {
+			return nil, fmt.Errorf("write block %d to scratch bundle: %w", blk.Number, err)
+		}
+	}
+
+	bundleFilename := fmt.Sprintf("%010d", baseNum)
+	data, err := os.ReadFile(filepath.Join(tmpDir, bundleFilename))
+	if err != nil {
+		return nil, fmt.Errorf("read scratch bundle %s: %w", bundleFilename, err)
+	}
+
+	return data, nil
+}